@@ -165,6 +165,9 @@ EVAL:
 		// Create.
 		case strings.HasPrefix(line, "\\create"):
 			c.create(line)
+		// Export.
+		case strings.HasPrefix(line, "\\export"):
+			c.export(line)
 		// Function help.
 		case strings.HasPrefix(line, "\\help") || strings.HasPrefix(line, "\\?"):
 			c.functionHelp(line)
@@ -200,6 +203,7 @@ func newReader() (*readline.Instance, error) {
 		readline.PcItem("\\show"),
 		readline.PcItem("\\load"),
 		readline.PcItem("\\create"),
+		readline.PcItem("\\export"),
 		readline.PcItem("\\trim"),
 		readline.PcItem("\\help"),
 		readline.PcItem("\\exit"),
@@ -0,0 +1,185 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseExportCommand(t *testing.T) {
+	spec, err := parseExportCommand(`\export csv /tmp/out select * from foo --max-size=1MB --max-rows=10`)
+	if err != nil {
+		t.Fatalf("parseExportCommand() error = %v", err)
+	}
+	if spec.format != formatCSV {
+		t.Errorf("format = %q, want %q", spec.format, formatCSV)
+	}
+	if spec.path != "/tmp/out" {
+		t.Errorf("path = %q, want %q", spec.path, "/tmp/out")
+	}
+	if spec.sql != "select * from foo" {
+		t.Errorf("sql = %q, want %q", spec.sql, "select * from foo")
+	}
+	if spec.maxSizeBytes != 1024*1024 {
+		t.Errorf("maxSizeBytes = %d, want %d", spec.maxSizeBytes, 1024*1024)
+	}
+	if spec.maxRows != 10 {
+		t.Errorf("maxRows = %d, want %d", spec.maxRows, 10)
+	}
+}
+
+func TestParseExportCommandRejectsUnknownFormat(t *testing.T) {
+	_, err := parseExportCommand(`\export xml /tmp/out select * from foo`)
+	if err == nil {
+		t.Fatal("parseExportCommand() error = nil, want error for unknown format")
+	}
+}
+
+func TestCSVEncoderWritesHeaderAndRows(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out")
+	enc, err := newCSVEncoder(path, defaultMaxSizeBytes, defaultMaxRows)
+	if err != nil {
+		t.Fatalf("newCSVEncoder() error = %v", err)
+	}
+
+	if err := enc.WriteHeader([]string{"Epoch", "Price"}); err != nil {
+		t.Fatalf("WriteHeader() error = %v", err)
+	}
+	if err := enc.WriteRow([]string{"123", "10.5"}); err != nil {
+		t.Fatalf("WriteRow() error = %v", err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	f, err := os.Open(path + ".0000.csv")
+	if err != nil {
+		t.Fatalf("opening output file: %v", err)
+	}
+	defer f.Close()
+
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatalf("reading csv: %v", err)
+	}
+	want := [][]string{{"Epoch", "Price"}, {"123", "10.5"}}
+	if len(rows) != len(want) {
+		t.Fatalf("got %d rows, want %d", len(rows), len(want))
+	}
+	for i := range want {
+		if rows[i][0] != want[i][0] || rows[i][1] != want[i][1] {
+			t.Errorf("row %d = %v, want %v", i, rows[i], want[i])
+		}
+	}
+}
+
+func TestCSVEncoderRotatesOnMaxRows(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out")
+	enc, err := newCSVEncoder(path, defaultMaxSizeBytes, 2)
+	if err != nil {
+		t.Fatalf("newCSVEncoder() error = %v", err)
+	}
+	defer enc.Close()
+
+	if err := enc.WriteHeader([]string{"Epoch"}); err != nil {
+		t.Fatalf("WriteHeader() error = %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := enc.WriteRow([]string{"1"}); err != nil {
+			t.Fatalf("WriteRow() error = %v", err)
+		}
+	}
+
+	if _, err := os.Stat(path + ".0000.csv"); err != nil {
+		t.Errorf("expected first shard to exist: %v", err)
+	}
+	if _, err := os.Stat(path + ".0001.csv"); err != nil {
+		t.Errorf("expected rotated second shard to exist: %v", err)
+	}
+}
+
+func TestJSONLEncoderWritesOneObjectPerLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out")
+	enc, err := newJSONLEncoder(path, defaultMaxSizeBytes, defaultMaxRows)
+	if err != nil {
+		t.Fatalf("newJSONLEncoder() error = %v", err)
+	}
+
+	if err := enc.WriteHeader([]string{"Epoch", "Price"}); err != nil {
+		t.Fatalf("WriteHeader() error = %v", err)
+	}
+	if err := enc.WriteRow([]string{"123", "10.5"}); err != nil {
+		t.Fatalf("WriteRow() error = %v", err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	f, err := os.Open(path + ".0000.jsonl")
+	if err != nil {
+		t.Fatalf("opening output file: %v", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() {
+		t.Fatal("expected one line of output")
+	}
+	var record map[string]string
+	if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+		t.Fatalf("unmarshalling line: %v", err)
+	}
+	if record["Epoch"] != "123" || record["Price"] != "10.5" {
+		t.Errorf("record = %v, want {Epoch:123 Price:10.5}", record)
+	}
+}
+
+func TestJSONLEncoderRotatesOnMaxRows(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out")
+	enc, err := newJSONLEncoder(path, defaultMaxSizeBytes, 1)
+	if err != nil {
+		t.Fatalf("newJSONLEncoder() error = %v", err)
+	}
+	defer enc.Close()
+
+	if err := enc.WriteHeader([]string{"Epoch"}); err != nil {
+		t.Fatalf("WriteHeader() error = %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := enc.WriteRow([]string{"1"}); err != nil {
+			t.Fatalf("WriteRow() error = %v", err)
+		}
+	}
+
+	if _, err := os.Stat(path + ".0000.jsonl"); err != nil {
+		t.Errorf("expected first shard to exist: %v", err)
+	}
+	if _, err := os.Stat(path + ".0001.jsonl"); err != nil {
+		t.Errorf("expected rotated second shard to exist: %v", err)
+	}
+}
+
+func TestParquetEncoderRotatesOnMaxRows(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out")
+	enc, err := newParquetEncoder(path, 1, []string{"Epoch"})
+	if err != nil {
+		t.Fatalf("newParquetEncoder() error = %v", err)
+	}
+	defer enc.Close()
+
+	for i := 0; i < 2; i++ {
+		if err := enc.WriteRow([]string{"1"}); err != nil {
+			t.Fatalf("WriteRow() error = %v", err)
+		}
+	}
+
+	if _, err := os.Stat(path + ".0000.parquet"); err != nil {
+		t.Errorf("expected first shard to exist: %v", err)
+	}
+	if _, err := os.Stat(path + ".0001.parquet"); err != nil {
+		t.Errorf("expected rotated second shard to exist: %v", err)
+	}
+}
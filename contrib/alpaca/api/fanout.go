@@ -0,0 +1,31 @@
+package api
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/alpacahq/marketstore/v4/contrib/alpaca/sinks"
+	"github.com/alpacahq/marketstore/v4/utils/log"
+)
+
+// fanOut writes msg to every sink, dropping (and counting a warning for)
+// any sink that doesn't accept the write within writeTimeout so a single
+// slow sink can't stall the feed. drops is indexed the same way as
+// outputSinks and accumulates the per-sink drop count; it's updated
+// atomically because ConnectionPool calls fanOut from multiple decode
+// worker goroutines sharing the same drops slice.
+func fanOut(outputSinks []sinks.Sink, drops []uint64, writeTimeout time.Duration, msg []byte) {
+	for i, sink := range outputSinks {
+		ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+		err := sink.Write(ctx, msg)
+		cancel()
+		if err != nil {
+			total := atomic.AddUint64(&drops[i], 1)
+			log.Warn("[alpaca] dropped message for sink {%s:%v,%s:%v,%s:%v}",
+				"sink", i,
+				"dropped_total", total,
+				"error", err)
+		}
+	}
+}
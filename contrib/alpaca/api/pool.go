@@ -0,0 +1,210 @@
+package api
+
+import (
+	"context"
+	"time"
+
+	"github.com/alpacahq/marketstore/v4/contrib/alpaca/config"
+	"github.com/alpacahq/marketstore/v4/contrib/alpaca/sinks"
+	"github.com/alpacahq/marketstore/v4/utils/log"
+)
+
+const (
+	defaultNumConnections     = 1
+	defaultPerShardBufferSize = 1024
+)
+
+// work is one undecoded message queued for the decode worker pool,
+// tagged with the shard it arrived on so metrics stay per-shard.
+type work struct {
+	shard int
+	msg   []byte
+}
+
+// decodeFunc turns one queued work item into zero or more downstream
+// JSON messages. v1 messages are already JSON and pass through
+// unchanged; v2 frames are batched msgpack and must be unmarshaled and
+// re-encoded per record, which is the CPU-bound work this pool exists
+// to spread across NumDecodeWorkers.
+type decodeFunc func(msg []byte) ([][]byte, error)
+
+// decodeForVersion picks the decodeFunc matching cfg.APIVersion.
+func decodeForVersion(version string) decodeFunc {
+	if version == config.APIVersionV2 {
+		return decodeV2Frame
+	}
+	return decodePassthrough
+}
+
+// decodePassthrough is the v1 decodeFunc: v1 messages arrive as a
+// single already-decoded JSON record, so there is nothing to do.
+func decodePassthrough(msg []byte) ([][]byte, error) {
+	return [][]byte{msg}, nil
+}
+
+// ConnectionPool partitions a large subscription across several
+// independent Stream connections (shards), so no single websocket or
+// decode loop becomes a bottleneck. Each shard owns its own reconnect
+// state; decoded messages from every shard are parsed/normalized by a
+// bounded worker pool before being handed off to outputSinks.
+type ConnectionPool struct {
+	shards  []Stream
+	metrics []*ShardMetrics
+
+	workCh chan work
+	decode decodeFunc
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewConnectionPool builds a ConnectionPool for cfg, sharding
+// cfg.Subscription across cfg.NumConnections connections (by consistent
+// hashing on symbol) and decoding with cfg.NumDecodeWorkers workers.
+func NewConnectionPool(cfg *config.Config, outputSinks []sinks.Sink) *ConnectionPool {
+	numConns := cfg.NumConnections
+	if numConns <= 0 {
+		numConns = defaultNumConnections
+	}
+	numWorkers := cfg.NumDecodeWorkers
+	if numWorkers <= 0 {
+		numWorkers = numConns
+	}
+	bufSize := cfg.PerShardBufferSize
+	if bufSize <= 0 {
+		bufSize = defaultPerShardBufferSize
+	}
+
+	shardSubs := partitionSubscription(cfg.Subscription, numConns)
+	workCh := make(chan work, bufSize*numConns)
+
+	pool := &ConnectionPool{
+		workCh: workCh,
+		decode: decodeForVersion(cfg.APIVersion),
+	}
+
+	for i, sub := range shardSubs {
+		metrics := newShardMetrics(i)
+		pool.metrics = append(pool.metrics, metrics)
+
+		shardCfg := *cfg
+		shardCfg.Subscription = sub
+		shardSink := &poolSink{shard: i, workCh: workCh, metrics: metrics}
+
+		stream := newPoolShardStream(&shardCfg, shardSink)
+		pool.shards = append(pool.shards, stream)
+
+		go countReconnects(stream, metrics)
+	}
+
+	pool.startWorkers(numWorkers, outputSinks)
+
+	return pool
+}
+
+// Metrics returns a snapshot of every shard's metrics, in shard order.
+func (p *ConnectionPool) Metrics() []Snapshot {
+	snapshots := make([]Snapshot, len(p.metrics))
+	for i, m := range p.metrics {
+		snapshots[i] = m.Snapshot()
+	}
+	return snapshots
+}
+
+// Start launches every shard's stream.
+func (p *ConnectionPool) Start(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	p.cancel = cancel
+	p.done = make(chan struct{})
+
+	for _, shard := range p.shards {
+		if err := shard.Start(ctx); err != nil {
+			cancel()
+			return err
+		}
+	}
+	return nil
+}
+
+// Stop terminates every shard's stream and the decode worker pool.
+func (p *ConnectionPool) Stop() {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	for _, shard := range p.shards {
+		shard.Stop()
+	}
+	close(p.workCh)
+}
+
+func countReconnects(stream Stream, metrics *ShardMetrics) {
+	for range stream.Reconnecting() {
+		metrics.recordReconnect()
+	}
+}
+
+// newPoolShardStream builds the Stream for one pool shard. Unlike
+// NewStream, it hands the shard's connection goroutine a raw, undecoded
+// frame sink: the CPU-bound msgpack decode for v2 is deferred to the
+// pool's own decode workers (see startWorkers) instead of running on
+// the shard's connection goroutine. v1 has no comparable decode step,
+// so its shard stream is unchanged.
+func newPoolShardStream(cfg *config.Config, sink sinks.Sink) Stream {
+	if cfg.APIVersion == config.APIVersionV2 {
+		stream := newV2Stream(cfg, []sinks.Sink{sink})
+		stream.rawFrames = true
+		return stream
+	}
+	return newV1Stream(cfg, []sinks.Sink{sink})
+}
+
+// startWorkers launches numWorkers goroutines that pull queued, still
+// undecoded messages off p.workCh, decode/normalize them with p.decode
+// and fan the result out to outputSinks. This is where the CPU-bound
+// decode work the pool exists to parallelize actually runs, spread
+// across NumDecodeWorkers goroutines instead of each shard's own
+// connection goroutine.
+func (p *ConnectionPool) startWorkers(numWorkers int, outputSinks []sinks.Sink) {
+	drops := make([]uint64, len(outputSinks))
+	for w := 0; w < numWorkers; w++ {
+		go func() {
+			for item := range p.workCh {
+				start := time.Now()
+				msgs, err := p.decode(item.msg)
+				if err != nil {
+					log.Error("[alpaca] pool failed to decode message {%s:%v}", "error", err)
+				}
+				for _, msg := range msgs {
+					fanOut(outputSinks, drops, defaultSinkWriteTimeout, msg)
+				}
+				p.metrics[item.shard].recordMessage(time.Since(start))
+				p.metrics[item.shard].decBacklog()
+			}
+		}()
+	}
+}
+
+// poolSink is the Sink a shard's stream writes into: rather than
+// forwarding straight to the final output sinks, it queues the message
+// for the pool's decode worker pool, so CPU-bound decode/normalize work
+// is spread across NumDecodeWorkers instead of running inline on the
+// shard's own goroutine.
+type poolSink struct {
+	shard   int
+	workCh  chan<- work
+	metrics *ShardMetrics
+}
+
+func (s *poolSink) Write(ctx context.Context, msg []byte) error {
+	s.metrics.incBacklog()
+	select {
+	case s.workCh <- work{shard: s.shard, msg: msg}:
+		return nil
+	case <-ctx.Done():
+		s.metrics.decBacklog()
+		return ctx.Err()
+	}
+}
+
+func (s *poolSink) Close() error {
+	return nil
+}
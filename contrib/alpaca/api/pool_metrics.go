@@ -0,0 +1,70 @@
+package api
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// ShardMetrics tracks per-shard throughput and health for a
+// ConnectionPool. All fields are safe for concurrent use.
+type ShardMetrics struct {
+	shard int
+
+	messages       uint64
+	decodeNanosSum uint64
+	decodeSamples  uint64
+	reconnectCount uint64
+	backlogDepth   int64
+}
+
+func newShardMetrics(shard int) *ShardMetrics {
+	return &ShardMetrics{shard: shard}
+}
+
+func (m *ShardMetrics) recordMessage(decodeLatency time.Duration) {
+	atomic.AddUint64(&m.messages, 1)
+	atomic.AddUint64(&m.decodeNanosSum, uint64(decodeLatency.Nanoseconds()))
+	atomic.AddUint64(&m.decodeSamples, 1)
+}
+
+func (m *ShardMetrics) recordReconnect() {
+	atomic.AddUint64(&m.reconnectCount, 1)
+}
+
+func (m *ShardMetrics) incBacklog() {
+	atomic.AddInt64(&m.backlogDepth, 1)
+}
+
+func (m *ShardMetrics) decBacklog() {
+	atomic.AddInt64(&m.backlogDepth, -1)
+}
+
+// Snapshot is a point-in-time read of a shard's metrics.
+type Snapshot struct {
+	Shard            int
+	Messages         uint64
+	AvgDecodeLatency time.Duration
+	ReconnectCount   uint64
+	BacklogDepth     int64
+}
+
+// Snapshot reads the current values of m. It is safe to call while the
+// pool is running.
+func (m *ShardMetrics) Snapshot() Snapshot {
+	messages := atomic.LoadUint64(&m.messages)
+	nanosSum := atomic.LoadUint64(&m.decodeNanosSum)
+	samples := atomic.LoadUint64(&m.decodeSamples)
+
+	var avg time.Duration
+	if samples > 0 {
+		avg = time.Duration(nanosSum / samples)
+	}
+
+	return Snapshot{
+		Shard:            m.shard,
+		Messages:         messages,
+		AvgDecodeLatency: avg,
+		ReconnectCount:   atomic.LoadUint64(&m.reconnectCount),
+		BacklogDepth:     atomic.LoadInt64(&m.backlogDepth),
+	}
+}
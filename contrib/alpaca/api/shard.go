@@ -0,0 +1,76 @@
+package api
+
+import (
+	"hash/fnv"
+	"sort"
+
+	"github.com/alpacahq/marketstore/v4/contrib/alpaca/config"
+)
+
+// virtualNodesPerShard controls how evenly symbols spread across shards;
+// more virtual nodes trade a little memory for a more even distribution.
+const virtualNodesPerShard = 100
+
+// shardRing consistently maps a symbol to one of numShards connections,
+// so a given symbol always lands on the same shard and its messages stay
+// in order relative to each other.
+type shardRing struct {
+	points []uint32
+	owner  map[uint32]int
+}
+
+func newShardRing(numShards int) *shardRing {
+	r := &shardRing{owner: make(map[uint32]int, numShards*virtualNodesPerShard)}
+	for shard := 0; shard < numShards; shard++ {
+		for v := 0; v < virtualNodesPerShard; v++ {
+			h := hashSymbol(shard, v)
+			r.points = append(r.points, h)
+			r.owner[h] = shard
+		}
+	}
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i] < r.points[j] })
+	return r
+}
+
+// ShardFor returns which shard owns symbol.
+func (r *shardRing) ShardFor(symbol string) int {
+	h := hashString(symbol)
+	idx := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+	if idx == len(r.points) {
+		idx = 0
+	}
+	return r.owner[r.points[idx]]
+}
+
+func hashSymbol(shard, virtualNode int) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte{byte(shard), byte(shard >> 8), byte(virtualNode), byte(virtualNode >> 8)})
+	return h.Sum32()
+}
+
+func hashString(s string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(s))
+	return h.Sum32()
+}
+
+// partitionSubscription splits sub across numShards using a shardRing, so
+// each shard gets its own config.Subscription to hand to an independent
+// Stream.
+func partitionSubscription(sub config.Subscription, numShards int) []config.Subscription {
+	ring := newShardRing(numShards)
+	shards := make([]config.Subscription, numShards)
+
+	assign := func(symbols []string, take func(*config.Subscription) *[]string) {
+		for _, sym := range symbols {
+			i := ring.ShardFor(sym)
+			dst := take(&shards[i])
+			*dst = append(*dst, sym)
+		}
+	}
+	assign(sub.Trades, func(s *config.Subscription) *[]string { return &s.Trades })
+	assign(sub.Quotes, func(s *config.Subscription) *[]string { return &s.Quotes })
+	assign(sub.Bars, func(s *config.Subscription) *[]string { return &s.Bars })
+
+	return shards
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/alpacahq/marketstore/v4/contrib/alpaca/config"
+)
+
+func TestShardRingIsStableForASymbol(t *testing.T) {
+	ring := newShardRing(4)
+
+	first := ring.ShardFor("AAPL")
+	for i := 0; i < 100; i++ {
+		if got := ring.ShardFor("AAPL"); got != first {
+			t.Fatalf("ShardFor(%q) = %d, want stable shard %d", "AAPL", got, first)
+		}
+	}
+}
+
+func TestPartitionSubscriptionKeepsEverySymbol(t *testing.T) {
+	sub := config.Subscription{
+		Trades: []string{"AAPL", "MSFT", "GOOG", "AMZN"},
+		Quotes: []string{"VOO"},
+		Bars:   []string{"SPY"},
+	}
+
+	shards := partitionSubscription(sub, 3)
+
+	var trades, quotes, bars []string
+	for _, s := range shards {
+		trades = append(trades, s.Trades...)
+		quotes = append(quotes, s.Quotes...)
+		bars = append(bars, s.Bars...)
+	}
+
+	if len(trades) != len(sub.Trades) {
+		t.Errorf("got %d trades across shards, want %d", len(trades), len(sub.Trades))
+	}
+	if len(quotes) != len(sub.Quotes) {
+		t.Errorf("got %d quotes across shards, want %d", len(quotes), len(sub.Quotes))
+	}
+	if len(bars) != len(sub.Bars) {
+		t.Errorf("got %d bars across shards, want %d", len(bars), len(sub.Bars))
+	}
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"context"
+
+	"github.com/alpacahq/marketstore/v4/contrib/alpaca/config"
+	"github.com/alpacahq/marketstore/v4/contrib/alpaca/sinks"
+)
+
+// Stream is a self-supervising Alpaca market data client: it dials,
+// authenticates, subscribes and fans decoded messages out to a set of
+// sinks, reconnecting automatically after transport errors.
+type Stream interface {
+	// Start launches the stream's supervisor goroutine. It returns once
+	// the goroutine has been launched; the goroutine itself keeps
+	// running, reconnecting as needed, until ctx is cancelled or Stop is
+	// called.
+	Start(ctx context.Context) error
+	// Stop terminates the supervisor goroutine and releases the stream's
+	// resources. It blocks until the supervisor has fully exited.
+	Stop()
+	// Reconnecting is sent a value whenever the stream begins a new
+	// reconnect attempt, primarily so tests can observe reconnect
+	// behavior deterministically.
+	Reconnecting() <-chan bool
+}
+
+// NewStream builds the Stream implementation selected by cfg.APIVersion,
+// fanning out decoded messages to outputSinks. outputSinks are typically
+// built with sinks.Build from cfg.Sinks.
+func NewStream(cfg *config.Config, outputSinks []sinks.Sink) Stream {
+	if cfg.APIVersion == config.APIVersionV2 {
+		return newV2Stream(cfg, outputSinks)
+	}
+	return newV1Stream(cfg, outputSinks)
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/alpacahq/marketstore/v4/utils/log"
+)
+
+const (
+	defaultMinBackoff = 500 * time.Millisecond
+	defaultMaxBackoff = 30 * time.Second
+	// healthyAfter is how long a connection must stay up before a
+	// subsequent failure resets the backoff back to minBackoff.
+	healthyAfter = 60 * time.Second
+)
+
+// errNonRetryable wraps an error that a supervisor should not attempt to
+// recover from, e.g. a stream auth failure.
+type errNonRetryable struct {
+	err error
+}
+
+func (e *errNonRetryable) Error() string { return e.err.Error() }
+func (e *errNonRetryable) Unwrap() error { return e.err }
+
+// supervisor runs a listen function in a loop, reconnecting with an
+// exponential backoff (with jitter) after any error, until ctx is
+// cancelled, Stop is called, MaxRetries consecutive attempts fail, or
+// listen returns a non-retryable error. It is shared by v1Stream and
+// v2Stream so both implementations of Stream reconnect identically.
+type supervisor struct {
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	maxRetries int
+
+	// reconnecting is sent a value every time a fresh reconnect attempt
+	// starts, so tests can observe reconnect behavior.
+	reconnecting chan bool
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func newSupervisor(minBackoff, maxBackoff time.Duration, maxRetries int) *supervisor {
+	if minBackoff <= 0 {
+		minBackoff = defaultMinBackoff
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = defaultMaxBackoff
+	}
+	return &supervisor{
+		minBackoff:   minBackoff,
+		maxBackoff:   maxBackoff,
+		maxRetries:   maxRetries,
+		reconnecting: make(chan bool, 1),
+	}
+}
+
+func (s *supervisor) Reconnecting() <-chan bool {
+	return s.reconnecting
+}
+
+// Start launches the supervisor loop, calling listen repeatedly until ctx
+// is cancelled or Stop is called.
+func (s *supervisor) Start(ctx context.Context, listen func(context.Context) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+
+	s.mu.Lock()
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	s.mu.Unlock()
+
+	go s.run(ctx, listen)
+
+	return nil
+}
+
+// Stop terminates the supervisor loop, waits for it to exit, and then
+// runs closeFn, if any, to release stream-specific resources (the
+// connection, configured sinks, ...).
+func (s *supervisor) Stop(closeFn func()) {
+	s.mu.Lock()
+	cancel, done := s.cancel, s.done
+	s.mu.Unlock()
+
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+
+	if closeFn != nil {
+		closeFn()
+	}
+}
+
+func (s *supervisor) run(ctx context.Context, listen func(context.Context) error) {
+	defer close(s.done)
+
+	backoff := s.minBackoff
+	retries := 0
+
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		select {
+		case s.reconnecting <- true:
+		default:
+		}
+
+		start := time.Now()
+		err := listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		var nonRetryable *errNonRetryable
+		if errors.As(err, &nonRetryable) {
+			log.Error("[alpaca] stream stopped retrying after a non-retryable error {%s:%v}", "error", err)
+			return
+		}
+
+		retries++
+		if err != nil {
+			log.Error("[alpaca] stream disconnected, reconnecting {%s:%v,%s:%v}",
+				"error", err,
+				"attempt", retries)
+		}
+
+		if s.maxRetries > 0 && retries >= s.maxRetries {
+			log.Error("[alpaca] giving up after %d consecutive reconnect attempts", retries)
+			return
+		}
+
+		if time.Since(start) >= healthyAfter {
+			backoff = s.minBackoff
+			retries = 0
+		}
+
+		wait := jitter(backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+
+		backoff *= 2
+		if backoff > s.maxBackoff {
+			backoff = s.maxBackoff
+		}
+	}
+}
+
+// jitter returns d plus or minus up to 20% of randomness, so that many
+// clients reconnecting at once don't all retry in lockstep.
+func jitter(d time.Duration) time.Duration {
+	// nolint:gomnd // +/- 20% jitter
+	delta := time.Duration(rand.Int63n(int64(d) / 5))
+	if rand.Intn(2) == 0 {
+		return d + delta
+	}
+	return d - delta
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -11,12 +12,21 @@ import (
 	"github.com/gorilla/websocket"
 
 	"github.com/alpacahq/marketstore/v4/contrib/alpaca/config"
+	"github.com/alpacahq/marketstore/v4/contrib/alpaca/sinks"
 	"github.com/alpacahq/marketstore/v4/utils/log"
 )
 
 var errExchangeMessage = errors.New("didn't receive expected message")
 
-type AlpacaWebSocket struct {
+// defaultSinkWriteTimeout bounds how long the stream waits on a single
+// sink before dropping the message for that sink.
+const defaultSinkWriteTimeout = time.Second
+
+// v1Stream speaks Alpaca's legacy v1 JSON protocol: "action: listen"
+// subscribe messages and "Q.VOO"/"T.AAPL"-prefixed channel names.
+type v1Stream struct {
+	*supervisor
+
 	maxMessageSize int64
 	pingPeriod     time.Duration
 	server         string
@@ -24,27 +34,62 @@ type AlpacaWebSocket struct {
 	apiSecret      string
 	subscriptions  []string
 	conn           *websocket.Conn
-	outputChan     chan<- interface{}
+
+	sinks            []sinks.Sink
+	sinkWriteTimeout time.Duration
+	// sinkDrops counts, per sink index, how many messages have been
+	// dropped because that sink didn't accept a write within
+	// sinkWriteTimeout.
+	sinkDrops []uint64
 }
 
-func NewAlpacaWebSocket(cfg *config.Config, oChan chan<- interface{}) *AlpacaWebSocket {
+func newV1Stream(cfg *config.Config, outputSinks []sinks.Sink) *v1Stream {
 	const defaultMaxMessageSizeInBytes = 2048000
-	return &AlpacaWebSocket{
-		maxMessageSize: defaultMaxMessageSizeInBytes,
-		pingPeriod:     10 * time.Second,
-		server:         cfg.WSServer,
-		apiKey:         cfg.APIKey,
-		apiSecret:      cfg.APISecret,
-		subscriptions:  cfg.Subscription.AsCanonical(),
-		conn:           nil,
-		outputChan:     oChan,
+
+	sinkWriteTimeout := cfg.SinkWriteTimeout
+	if sinkWriteTimeout <= 0 {
+		sinkWriteTimeout = defaultSinkWriteTimeout
+	}
+
+	return &v1Stream{
+		supervisor:       newSupervisor(cfg.MinBackoff, cfg.MaxBackoff, cfg.MaxRetries),
+		maxMessageSize:   defaultMaxMessageSizeInBytes,
+		pingPeriod:       10 * time.Second,
+		server:           cfg.WSServer,
+		apiKey:           cfg.APIKey,
+		apiSecret:        cfg.APISecret,
+		subscriptions:    cfg.Subscription.AsCanonical(),
+		sinks:            outputSinks,
+		sinkWriteTimeout: sinkWriteTimeout,
+		sinkDrops:        make([]uint64, len(outputSinks)),
 	}
 }
 
+// Start launches the supervisor goroutine, which dials, authenticates,
+// subscribes and runs the read/ping/pong loop, reconnecting with an
+// exponential backoff after any transport error, unexpected close frame
+// or missed pong.
+func (p *v1Stream) Start(ctx context.Context) error {
+	return p.supervisor.Start(ctx, p.listen)
+}
+
+// Stop terminates the supervisor goroutine and closes the underlying
+// connection and configured sinks. It blocks until the supervisor has
+// fully exited.
+func (p *v1Stream) Stop() {
+	p.supervisor.Stop(func() {
+		for i, sink := range p.sinks {
+			if err := sink.Close(); err != nil {
+				log.Error("[alpaca] failed to close sink {%s:%v,%s:%v}", "sink", i, "error", err)
+			}
+		}
+	})
+}
+
 // listen sets up a websocket connection, authenticates
 // and sets up listening. It returns with the error that
 // resulted in the connection getting closed.
-func (p *AlpacaWebSocket) listen() error {
+func (p *v1Stream) listen(ctx context.Context) error {
 	// start the websocket connection
 	if err := p.connect(); err != nil {
 		log.Error("[alpaca] error connecting to server {%s:%v,%s:%v,%s:%s}",
@@ -80,7 +125,12 @@ func (p *AlpacaWebSocket) listen() error {
 
 	for {
 		select {
+		case <-ctx.Done():
+			return nil
 		case err := <-errorChan:
+			// A read-deadline expiry (missed pong) surfaces here just
+			// like any other read error; treat it as a soft failure
+			// that the supervisor will reconnect from.
 			return err
 		case <-ticker.C:
 			err := p.conn.WriteControl(websocket.PingMessage, []byte{}, time.Now().Add(time.Second))
@@ -89,17 +139,17 @@ func (p *AlpacaWebSocket) listen() error {
 				return err
 			}
 		case msg := <-out:
-			p.outputChan <- msg
+			fanOut(p.sinks, p.sinkDrops, p.sinkWriteTimeout, msg)
 		}
 	}
 }
 
-func (p *AlpacaWebSocket) setReadDeadline() error {
+func (p *v1Stream) setReadDeadline() error {
 	// nolint:gomnd // specifying a value slightly larger than the ping period
 	return p.conn.SetReadDeadline(time.Now().Add((p.pingPeriod * 6) / 5))
 }
 
-func (p *AlpacaWebSocket) receiveMessages(out chan<- []byte, errorChan chan<- error) {
+func (p *v1Stream) receiveMessages(out chan<- []byte, errorChan chan<- error) {
 	for {
 		tt, pp, err := p.conn.ReadMessage()
 		if err != nil {
@@ -118,16 +168,18 @@ func (p *AlpacaWebSocket) receiveMessages(out chan<- []byte, errorChan chan<- er
 	}
 }
 
-func (p *AlpacaWebSocket) connect() (err error) {
+func (p *v1Stream) connect() (err error) {
 	var hresp *http.Response
 	dialer := websocket.DefaultDialer
 	dialer.HandshakeTimeout = 2 * time.Second
 	p.conn, hresp, err = dialer.Dial(p.server, nil)
-	defer func(Body io.ReadCloser) {
-		if err2 := Body.Close(); err2 != nil {
-			log.Error("failed to close websocket response body:" + err2.Error())
-		}
-	}(hresp.Body)
+	if hresp != nil {
+		defer func(Body io.ReadCloser) {
+			if err2 := Body.Close(); err2 != nil {
+				log.Error("failed to close websocket response body:" + err2.Error())
+			}
+		}(hresp.Body)
+	}
 	if err != nil {
 		if hresp != nil {
 			body, _ := io.ReadAll(hresp.Body)
@@ -148,7 +200,7 @@ func (p *AlpacaWebSocket) connect() (err error) {
 
 // Subscribe sends the necessary messages through p.conn
 // to authorize the user and subscribe to streams.
-func (p *AlpacaWebSocket) subscribe() error {
+func (p *v1Stream) subscribe() error {
 	var (
 		err  error
 		resp string
@@ -195,7 +247,7 @@ func (p *AlpacaWebSocket) subscribe() error {
 	return p.setReadDeadline()
 }
 
-func (p *AlpacaWebSocket) exchangeMessage(send, expect string) (response string, err error) {
+func (p *v1Stream) exchangeMessage(send, expect string) (response string, err error) {
 	err = p.conn.WriteMessage(websocket.TextMessage, []byte(send))
 	if err != nil {
 		return "", err
@@ -206,15 +258,15 @@ func (p *AlpacaWebSocket) exchangeMessage(send, expect string) (response string,
 		if err != nil {
 			return "", err
 		}
-	
+
 		response = string(pp)
 		if strings.Contains(response, expect) {
-			break  // Exit the loop when the expected response is found
+			break // Exit the loop when the expected response is found
 		}
-	
+
 		// Optionally, handle unexpected messages
 		log.Info("[javierlopeza] response: %s, expect: %s", response, expect)
 	}
-	
+
 	return response, nil
 }
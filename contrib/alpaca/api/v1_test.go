@@ -0,0 +1,119 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/alpacahq/marketstore/v4/contrib/alpaca/config"
+	"github.com/alpacahq/marketstore/v4/contrib/alpaca/sinks"
+)
+
+// newTestServer spins up a websocket server that performs the v1 auth +
+// subscribe handshake and then force-closes the connection after
+// forceCloseAfter messages, simulating repeated transport failures.
+func newTestServer(t *testing.T, forceCloseAfter int) *httptest.Server {
+	t.Helper()
+
+	upgrader := websocket.Upgrader{}
+	var closes int
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade failed: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		// auth
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(`"authenticated"`)); err != nil {
+			return
+		}
+
+		// subscribe
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"streams":["T.AAPL"]}`)); err != nil {
+			return
+		}
+
+		if closes < forceCloseAfter {
+			closes++
+			conn.Close()
+			return
+		}
+
+		for i := 0; i < 3; i++ {
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"stream":"T.AAPL"}`)); err != nil {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		// keep the connection open for pings.
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+}
+
+func TestAlpacaWebSocketReconnect(t *testing.T) {
+	const forcedCloses = 3
+
+	server := newTestServer(t, forcedCloses)
+	defer server.Close()
+
+	cfg := &config.Config{
+		WSServer:   "ws" + strings.TrimPrefix(server.URL, "http"),
+		APIKey:     "key",
+		APISecret:  "secret",
+		MinBackoff: time.Millisecond,
+		MaxBackoff: 5 * time.Millisecond,
+		Subscription: config.Subscription{
+			Trades: []string{"AAPL"},
+		},
+	}
+
+	out := make(chan interface{}, 16)
+	ws := NewStream(cfg, []sinks.Sink{sinks.NewChannelSink(out)})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := ws.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer ws.Stop()
+
+	reconnects := 0
+	timeout := time.After(5 * time.Second)
+	for reconnects <= forcedCloses {
+		select {
+		case <-ws.Reconnecting():
+			reconnects++
+		case <-timeout:
+			t.Fatalf("timed out waiting for %d reconnects, saw %d", forcedCloses, reconnects)
+		}
+	}
+
+	select {
+	case msg := <-out:
+		if msg == nil {
+			t.Fatal("expected a non-nil message after reconnecting")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a message to flow after reconnecting")
+	}
+}
@@ -0,0 +1,315 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/alpacahq/marketstore/v4/contrib/alpaca/config"
+	"github.com/alpacahq/marketstore/v4/contrib/alpaca/sinks"
+	"github.com/alpacahq/marketstore/v4/utils/log"
+)
+
+// nonRetryableAuthCodes are v2 stream error codes returned during the
+// auth/subscribe handshake that will never succeed on retry, so the
+// supervisor should give up instead of reconnecting.
+var nonRetryableAuthCodes = map[int]bool{
+	402: true, // auth timeout
+	404: true, // auth failed
+	406: true, // connection limit exceeded
+	409: true, // slow client
+}
+
+// v2ControlMessage is the handshake envelope the v2 stream uses for
+// "success" and "error" control messages.
+type v2ControlMessage struct {
+	Type string `msgpack:"T"`
+	Msg  string `msgpack:"msg"`
+	Code int    `msgpack:"code"`
+}
+
+// v2subscribeMsg is the subscribe payload for the v2 stream, sent as
+// JSON text like the auth message, e.g.
+// {"action":"subscribe","trades":["AAPL"],"quotes":["*"],"bars":["SPY"]}.
+type v2subscribeMsg struct {
+	Action string   `json:"action"`
+	Trades []string `json:"trades,omitempty"`
+	Quotes []string `json:"quotes,omitempty"`
+	Bars   []string `json:"bars,omitempty"`
+}
+
+// v2Stream speaks Alpaca's v2 market data stream: msgpack-encoded binary
+// arrays of typed messages (trades, quotes, bars, statuses, corrections,
+// cancels) over a JSON auth/subscribe handshake.
+type v2Stream struct {
+	*supervisor
+
+	maxMessageSize int64
+	pingPeriod     time.Duration
+	server         string
+	apiKey         string
+	apiSecret      string
+	subscription   config.Subscription
+	conn           *websocket.Conn
+
+	sinks            []sinks.Sink
+	sinkWriteTimeout time.Duration
+	sinkDrops        []uint64
+
+	// rawFrames, when set by the pool, skips this stream's own msgpack
+	// decode and hands each frame to p.sinks undecoded. Used only by
+	// ConnectionPool, which does the decode itself in its worker pool
+	// instead of on the shard's connection goroutine. Plain NewStream
+	// callers always leave this false.
+	rawFrames bool
+}
+
+func newV2Stream(cfg *config.Config, outputSinks []sinks.Sink) *v2Stream {
+	const defaultMaxMessageSizeInBytes = 2048000
+
+	sinkWriteTimeout := cfg.SinkWriteTimeout
+	if sinkWriteTimeout <= 0 {
+		sinkWriteTimeout = defaultSinkWriteTimeout
+	}
+
+	return &v2Stream{
+		supervisor:       newSupervisor(cfg.MinBackoff, cfg.MaxBackoff, cfg.MaxRetries),
+		maxMessageSize:   defaultMaxMessageSizeInBytes,
+		pingPeriod:       10 * time.Second,
+		server:           cfg.WSServer,
+		apiKey:           cfg.APIKey,
+		apiSecret:        cfg.APISecret,
+		subscription:     cfg.Subscription,
+		sinks:            outputSinks,
+		sinkWriteTimeout: sinkWriteTimeout,
+		sinkDrops:        make([]uint64, len(outputSinks)),
+	}
+}
+
+func (p *v2Stream) Start(ctx context.Context) error {
+	return p.supervisor.Start(ctx, p.listen)
+}
+
+func (p *v2Stream) Stop() {
+	p.supervisor.Stop(func() {
+		for i, sink := range p.sinks {
+			if err := sink.Close(); err != nil {
+				log.Error("[alpaca] failed to close sink {%s:%v,%s:%v}", "sink", i, "error", err)
+			}
+		}
+	})
+}
+
+func (p *v2Stream) listen(ctx context.Context) error {
+	if err := p.connect(); err != nil {
+		log.Error("[alpaca] v2 error connecting to server {%s:%v,%s:%v}",
+			"server", p.server,
+			"error", err)
+		return err
+	}
+	defer func(conn *websocket.Conn) {
+		if err := conn.Close(); err != nil {
+			log.Error("failed to close websocket connection", err.Error())
+		}
+	}(p.conn)
+
+	p.conn.SetReadLimit(p.maxMessageSize)
+	p.conn.SetPongHandler(func(string) error {
+		return p.setReadDeadline()
+	})
+
+	if err := p.handshake(); err != nil {
+		return err
+	}
+
+	out, errorChan := make(chan []byte), make(chan error, 1)
+	go p.receiveMessages(out, errorChan)
+	ticker := time.NewTicker(p.pingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-errorChan:
+			return err
+		case <-ticker.C:
+			err := p.conn.WriteControl(websocket.PingMessage, []byte{}, time.Now().Add(time.Second))
+			if err != nil {
+				log.Error("[alpaca] v2 stream write ping error %s", err)
+				return err
+			}
+		case frame := <-out:
+			if p.rawFrames {
+				fanOut(p.sinks, p.sinkDrops, p.sinkWriteTimeout, frame)
+				continue
+			}
+			p.dispatch(frame)
+		}
+	}
+}
+
+func (p *v2Stream) setReadDeadline() error {
+	// nolint:gomnd // specifying a value slightly larger than the ping period
+	return p.conn.SetReadDeadline(time.Now().Add((p.pingPeriod * 6) / 5))
+}
+
+func (p *v2Stream) connect() (err error) {
+	var hresp *http.Response
+	dialer := websocket.DefaultDialer
+	dialer.HandshakeTimeout = 2 * time.Second
+	p.conn, hresp, err = dialer.Dial(p.server, nil)
+	if hresp != nil {
+		defer func(Body io.ReadCloser) {
+			if err2 := Body.Close(); err2 != nil {
+				log.Error("failed to close websocket response body:" + err2.Error())
+			}
+		}(hresp.Body)
+	}
+	if err != nil {
+		if hresp != nil {
+			body, _ := io.ReadAll(hresp.Body)
+			return fmt.Errorf(
+				"[alpaca] v2 connection failure, err: %w, status_code: %d, body: %s",
+				err,
+				hresp.StatusCode,
+				body,
+			)
+		}
+		return fmt.Errorf("[alpaca] v2 connection failure, err: %w", err)
+	}
+	return nil
+}
+
+// handshake authenticates and subscribes, honoring the "success"/"error"
+// control messages the v2 stream returns during the process. An "error"
+// carrying a non-retryable code is wrapped so the supervisor stops
+// reconnecting instead of retrying forever.
+func (p *v2Stream) handshake() error {
+	authMsg := fmt.Sprintf(`{"action":"auth","key":"%s","secret":"%s"}`, p.apiKey, p.apiSecret)
+	if err := p.conn.WriteMessage(websocket.TextMessage, []byte(authMsg)); err != nil {
+		return err
+	}
+	if err := p.awaitSuccess("authenticated"); err != nil {
+		return err
+	}
+	log.Info("[alpaca] v2 authenticated successfully")
+
+	subMsg, err := json.Marshal(v2subscribeMsg{
+		Action: "subscribe",
+		Trades: p.subscription.Trades,
+		Quotes: p.subscription.Quotes,
+		Bars:   p.subscription.Bars,
+	})
+	if err != nil {
+		return err
+	}
+	if err := p.conn.WriteMessage(websocket.TextMessage, subMsg); err != nil {
+		return err
+	}
+	if err := p.awaitSuccess("subscription"); err != nil {
+		return err
+	}
+	log.Info("[alpaca] v2 subscribed {%s:%v}", "subscription", p.subscription)
+
+	return p.setReadDeadline()
+}
+
+// awaitSuccess reads control messages until it sees a "success" whose Msg
+// matches want, or an "error", which it turns into an error (wrapped as
+// non-retryable for codes that will never succeed on retry).
+func (p *v2Stream) awaitSuccess(want string) error {
+	for {
+		_, raw, err := p.conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+
+		var msgs []v2ControlMessage
+		if err := msgpack.Unmarshal(raw, &msgs); err != nil {
+			// Some deployments reply to the handshake in JSON rather than
+			// msgpack; fall back before giving up on the frame.
+			if err := json.Unmarshal(raw, &msgs); err != nil {
+				log.Info("[alpaca] v2 unrecognized handshake frame: %s", raw)
+				continue
+			}
+		}
+
+		for _, m := range msgs {
+			switch m.Type {
+			case "error":
+				err := fmt.Errorf("[alpaca] v2 stream error %d: %s", m.Code, m.Msg)
+				if nonRetryableAuthCodes[m.Code] {
+					return &errNonRetryable{err: err}
+				}
+				return err
+			case "success":
+				if m.Msg == want || want == "" {
+					return nil
+				}
+			}
+		}
+	}
+}
+
+func (p *v2Stream) receiveMessages(out chan<- []byte, errorChan chan<- error) {
+	for {
+		tt, pp, err := p.conn.ReadMessage()
+		if err != nil {
+			log.Error("[alpaca] v2 error during reading {%s:%s}", "error", err)
+			errorChan <- err
+			return
+		}
+		if tt != websocket.BinaryMessage {
+			log.Warn("[alpaca] v2 received non-binary message from server")
+			continue
+		}
+		out <- pp
+	}
+}
+
+// dispatch decodes a binary frame into its typed records ("t" trades,
+// "q" quotes, "b" bars, "s" statuses, "c" corrections, "x" cancels) and
+// fans each one out to the configured sinks as an individual JSON
+// message, so corrections and cancels reach the writer the same way
+// trades and quotes do and can be used to amend historical bars.
+func (p *v2Stream) dispatch(frame []byte) {
+	msgs, err := decodeV2Frame(frame)
+	if err != nil {
+		log.Error("[alpaca] v2 failed to decode frame {%s:%v}", "error", err)
+		return
+	}
+
+	for _, msg := range msgs {
+		fanOut(p.sinks, p.sinkDrops, p.sinkWriteTimeout, msg)
+	}
+}
+
+// decodeV2Frame unmarshals a v2 binary frame into its individual typed
+// records and re-encodes each one as a standalone JSON message. This is
+// the CPU-bound step of the v2 path; it is called either inline by
+// dispatch or, under ConnectionPool, from a decode worker goroutine so
+// it doesn't run on the shard's own connection goroutine.
+func decodeV2Frame(frame []byte) ([][]byte, error) {
+	var records []map[string]interface{}
+	if err := msgpack.Unmarshal(frame, &records); err != nil {
+		return nil, err
+	}
+
+	msgs := make([][]byte, 0, len(records))
+	for _, rec := range records {
+		msg, err := json.Marshal(rec)
+		if err != nil {
+			log.Error("[alpaca] v2 failed to re-encode record {%s:%v}", "error", err)
+			continue
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/alpacahq/marketstore/v4/contrib/alpaca/config"
+	"github.com/alpacahq/marketstore/v4/contrib/alpaca/sinks"
+)
+
+// newV2TestServer performs the v2 auth/subscribe handshake in msgpack and
+// then emits one binary frame containing a mixed batch of a trade, a
+// quote, a bar and a trade correction.
+func newV2TestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	upgrader := websocket.Upgrader{}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade failed: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		if _, _, err := conn.ReadMessage(); err != nil { // auth
+			return
+		}
+		authOK, _ := msgpack.Marshal([]v2ControlMessage{{Type: "success", Msg: "authenticated"}})
+		if err := conn.WriteMessage(websocket.BinaryMessage, authOK); err != nil {
+			return
+		}
+
+		if _, _, err := conn.ReadMessage(); err != nil { // subscribe
+			return
+		}
+		subOK, _ := msgpack.Marshal([]v2ControlMessage{{Type: "success", Msg: "subscription"}})
+		if err := conn.WriteMessage(websocket.BinaryMessage, subOK); err != nil {
+			return
+		}
+
+		batch, _ := msgpack.Marshal([]map[string]interface{}{
+			{"T": "t", "S": "AAPL", "p": 150.25},
+			{"T": "q", "S": "AAPL", "bp": 150.20, "ap": 150.30},
+			{"T": "b", "S": "AAPL", "o": 150.0, "c": 150.5},
+			{"T": "c", "S": "AAPL", "tp": 150.25, "tc": []string{"Z"}},
+		})
+		if err := conn.WriteMessage(websocket.BinaryMessage, batch); err != nil {
+			return
+		}
+
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+}
+
+func TestV2StreamDispatchesMixedBatch(t *testing.T) {
+	server := newV2TestServer(t)
+	defer server.Close()
+
+	cfg := &config.Config{
+		WSServer:   "ws" + strings.TrimPrefix(server.URL, "http"),
+		APIKey:     "key",
+		APISecret:  "secret",
+		APIVersion: config.APIVersionV2,
+		Subscription: config.Subscription{
+			Trades: []string{"AAPL"},
+			Quotes: []string{"AAPL"},
+			Bars:   []string{"AAPL"},
+		},
+	}
+
+	out := make(chan interface{}, 16)
+	stream := NewStream(cfg, []sinks.Sink{sinks.NewChannelSink(out)})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := stream.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer stream.Stop()
+
+	seen := map[string]bool{}
+	timeout := time.After(5 * time.Second)
+	for len(seen) < 4 {
+		select {
+		case msg := <-out:
+			var rec map[string]interface{}
+			if err := json.Unmarshal(msg.([]byte), &rec); err != nil {
+				t.Fatalf("failed to unmarshal sink message: %v", err)
+			}
+			seen[rec["T"].(string)] = true
+		case <-timeout:
+			t.Fatalf("timed out waiting for all record types, saw %v", seen)
+		}
+	}
+
+	for _, want := range []string{"t", "q", "b", "c"} {
+		if !seen[want] {
+			t.Errorf("expected to see a %q record reach the sink", want)
+		}
+	}
+}
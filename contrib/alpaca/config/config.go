@@ -0,0 +1,121 @@
+// Package config defines the configuration for the Alpaca streaming plugin.
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// Config holds the settings required to connect to the Alpaca data stream
+// and the set of subscriptions to request once connected.
+type Config struct {
+	APIKey       string
+	APISecret    string
+	WSServer     string
+	Subscription Subscription
+
+	// APIVersion selects the wire protocol to speak: APIVersionV1 (the
+	// default) for the legacy JSON stream, or APIVersionV2 for the
+	// msgpack binary stream.
+	APIVersion string
+
+	// MinBackoff is the initial delay used before the first reconnect
+	// attempt. It doubles on each consecutive failure. Defaults to 500ms
+	// when unset.
+	MinBackoff time.Duration
+	// MaxBackoff caps the reconnect delay. Defaults to 30s when unset.
+	MaxBackoff time.Duration
+	// MaxRetries bounds the number of consecutive reconnect attempts.
+	// 0 (the default) means retry forever.
+	MaxRetries int
+
+	// Sinks lists the output destinations decoded messages are fanned out
+	// to. When empty, a single ChannelSink is assumed so the plugin keeps
+	// its original single-channel behavior.
+	Sinks []SinkConfig
+	// SinkWriteTimeout bounds how long the stream will wait on a single
+	// sink before dropping the message for that sink and counting a
+	// warning, so one slow sink can't stall the whole feed. Defaults to
+	// 1s when unset.
+	SinkWriteTimeout time.Duration
+
+	// NumConnections is the number of sharded connections a
+	// ConnectionPool splits the subscription across. Defaults to 1
+	// (a single connection) when unset.
+	NumConnections int
+	// NumDecodeWorkers is the size of the worker pool a ConnectionPool
+	// uses to parse/normalize messages concurrently. Defaults to
+	// NumConnections when unset.
+	NumDecodeWorkers int
+	// PerShardBufferSize bounds how many undecoded messages a shard may
+	// have queued for the worker pool before it starts blocking that
+	// shard's stream. Defaults to 1024 when unset.
+	PerShardBufferSize int
+}
+
+// API protocol versions accepted in Config.APIVersion.
+const (
+	APIVersionV1 = "v1"
+	APIVersionV2 = "v2"
+)
+
+// Sink type identifiers accepted in SinkConfig.Type.
+const (
+	SinkTypeChannel = "channel"
+	SinkTypeFile    = "file"
+	SinkTypeHTTP    = "http"
+)
+
+// SinkConfig selects and configures one output sink for the stream.
+type SinkConfig struct {
+	Type string
+	File *FileSinkConfig
+	HTTP *HTTPSinkConfig
+}
+
+// FileSinkConfig configures a FileSink, which writes newline-delimited
+// JSON records to Path, rotating once MaxSizeMB is exceeded and pruning
+// old rotations by MaxAgeDays and MaxBackups.
+type FileSinkConfig struct {
+	Path       string
+	MaxSizeMB  int64
+	MaxAgeDays int
+	MaxBackups int
+}
+
+// HTTPSinkConfig configures an HTTPSink, which POSTs batches of messages
+// to URL.
+type HTTPSinkConfig struct {
+	URL string
+	// BatchSize is the number of messages buffered before a POST is
+	// triggered. Defaults to 100 when unset.
+	BatchSize int
+	// FlushInterval bounds how long messages may sit buffered before a
+	// POST is triggered regardless of BatchSize. Defaults to 1s when
+	// unset.
+	FlushInterval time.Duration
+	// MaxRetries bounds the number of retries on a failed POST. 0 means
+	// no retries.
+	MaxRetries int
+}
+
+// Subscription describes the set of trade, quote and bar symbols to
+// listen for. Bars is only meaningful on the v2 stream.
+type Subscription struct {
+	Trades []string
+	Quotes []string
+	Bars   []string
+}
+
+// AsCanonical returns the v1 stream's channel names, e.g. "T.AAPL" for a
+// trade subscription on AAPL and "Q.VOO" for a quote subscription on VOO.
+func (s Subscription) AsCanonical() []string {
+	streams := make([]string, 0, len(s.Trades)+len(s.Quotes))
+	for _, sym := range s.Trades {
+		streams = append(streams, fmt.Sprintf("T.%s", sym))
+	}
+	for _, sym := range s.Quotes {
+		streams = append(streams, fmt.Sprintf("Q.%s", sym))
+	}
+	return streams
+}
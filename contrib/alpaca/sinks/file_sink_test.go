@@ -0,0 +1,78 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alpacahq/marketstore/v4/contrib/alpaca/config"
+)
+
+func TestFileSinkRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "alpaca.ndjson")
+
+	sink, err := NewFileSink(config.FileSinkConfig{
+		Path:       path,
+		MaxSizeMB:  1,
+		MaxBackups: 1,
+	})
+	if err != nil {
+		t.Fatalf("NewFileSink() error = %v", err)
+	}
+	defer sink.Close()
+
+	ctx := context.Background()
+	bigMsg := bytes.Repeat([]byte("a"), 300*1024)
+	for i := 0; i < 5; i++ {
+		if err := sink.Write(ctx, bigMsg); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("expected current file to exist: %v", err)
+	}
+
+	matches, err := filepath.Glob(path + ".*")
+	if err != nil {
+		t.Fatalf("Glob() error = %v", err)
+	}
+	if len(matches) == 0 {
+		t.Fatal("expected at least one rotated backup file")
+	}
+	if len(matches) > 1 {
+		t.Fatalf("expected MaxBackups=1 to prune old backups, found %d", len(matches))
+	}
+}
+
+// TestFileSinkWriteDoesNotMutateCallerSlice guards against Write
+// appending onto msg's backing array: msg is shared with every other
+// configured sink, so growing into its spare capacity would corrupt
+// whatever another sink does with the same slice afterward.
+func TestFileSinkWriteDoesNotMutateCallerSlice(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "alpaca.ndjson")
+
+	sink, err := NewFileSink(config.FileSinkConfig{Path: path})
+	if err != nil {
+		t.Fatalf("NewFileSink() error = %v", err)
+	}
+	defer sink.Close()
+
+	msg := make([]byte, 3, 8)
+	copy(msg, "abc")
+
+	if err := sink.Write(context.Background(), msg); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	if got := string(msg[:3]); got != "abc" {
+		t.Fatalf("Write() mutated caller's slice, got %q", got)
+	}
+	if cap(msg) != 8 || len(msg) != 3 {
+		t.Fatalf("Write() changed caller's slice len/cap: len=%d cap=%d", len(msg), cap(msg))
+	}
+}
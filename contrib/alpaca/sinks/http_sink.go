@@ -0,0 +1,136 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/alpacahq/marketstore/v4/contrib/alpaca/config"
+	"github.com/alpacahq/marketstore/v4/utils/log"
+)
+
+// HTTPSink batches messages and POSTs them, newline-delimited, to a
+// configured URL, retrying failed deliveries with a fixed backoff.
+type HTTPSink struct {
+	cfg    config.HTTPSinkConfig
+	client *http.Client
+
+	mu      sync.Mutex
+	batch   [][]byte
+	flushed time.Time
+}
+
+// NewHTTPSink builds an HTTPSink from cfg, applying defaults for any
+// unset batching parameters.
+func NewHTTPSink(cfg config.HTTPSinkConfig) *HTTPSink {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = time.Second
+	}
+	return &HTTPSink{
+		cfg:     cfg,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		flushed: time.Now(),
+	}
+}
+
+func (s *HTTPSink) Write(ctx context.Context, msg []byte) error {
+	s.mu.Lock()
+	s.batch = append(s.batch, msg)
+	shouldFlush := len(s.batch) >= s.cfg.BatchSize || time.Since(s.flushed) >= s.cfg.FlushInterval
+	s.mu.Unlock()
+
+	if shouldFlush {
+		return s.Flush(ctx)
+	}
+	return nil
+}
+
+// Flush posts any buffered messages and resets the batch, even on
+// failure, so one bad batch can't grow unbounded.
+func (s *HTTPSink) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	batch := s.batch
+	s.batch = nil
+	s.flushed = time.Now()
+	s.mu.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+	return s.postWithRetry(ctx, batch)
+}
+
+func (s *HTTPSink) postWithRetry(ctx context.Context, batch [][]byte) error {
+	var body bytes.Buffer
+	for _, msg := range batch {
+		body.Write(msg)
+		body.WriteByte('\n')
+	}
+	payload := body.Bytes()
+
+	maxRetries := s.cfg.MaxRetries
+	if maxRetries < 0 {
+		maxRetries = 0
+	}
+	backoff := 500 * time.Millisecond
+
+	var lastErr error
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			if backoff < 30*time.Second {
+				backoff *= 2
+			}
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.URL, bytes.NewReader(payload))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/x-ndjson")
+
+		resp, err := s.client.Do(req)
+		if err != nil {
+			lastErr = err
+			log.Warn("[alpaca] http sink post failed, retrying {%s:%v,%s:%v}", "attempt", attempt, "error", err)
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			lastErr = fmt.Errorf("http sink post returned status %d", resp.StatusCode)
+			if resp.StatusCode < http.StatusInternalServerError {
+				// 4xx responses (bad URL, auth failure, rejected payload)
+				// won't succeed on retry, so give up immediately instead
+				// of burning the remaining attempts against the same
+				// permanent failure.
+				log.Warn("[alpaca] http sink post rejected, giving up {%s:%v}", "error", lastErr)
+				return lastErr
+			}
+			log.Warn("[alpaca] http sink post failed, retrying {%s:%v,%s:%v}", "attempt", attempt, "error", lastErr)
+			continue
+		}
+		return nil
+	}
+	return fmt.Errorf("http sink giving up after %d attempts: %w", maxRetries+1, lastErr)
+}
+
+// defaultCloseFlushTimeout bounds the final flush Close triggers, so a
+// stream Stop() against an unreachable endpoint can't hang shutdown
+// indefinitely even if MaxRetries allows retrying.
+const defaultCloseFlushTimeout = 5 * time.Second
+
+func (s *HTTPSink) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultCloseFlushTimeout)
+	defer cancel()
+	return s.Flush(ctx)
+}
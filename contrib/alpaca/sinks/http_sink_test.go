@@ -0,0 +1,68 @@
+package sinks
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alpacahq/marketstore/v4/contrib/alpaca/config"
+)
+
+func TestHTTPSinkZeroMaxRetriesDoesNotRetry(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	sink := NewHTTPSink(config.HTTPSinkConfig{URL: server.URL, BatchSize: 1})
+	defer sink.Close()
+
+	if err := sink.Write(context.Background(), []byte(`{"T":"t"}`)); err == nil {
+		t.Fatal("Write() error = nil, want error from failing endpoint")
+	}
+
+	if got := atomic.LoadInt32(&attempts); got != 1 {
+		t.Errorf("attempts = %d, want 1 (MaxRetries=0 means no retries)", got)
+	}
+}
+
+func TestHTTPSinkNonRetryable4xxFailsImmediately(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	sink := NewHTTPSink(config.HTTPSinkConfig{URL: server.URL, BatchSize: 1, MaxRetries: 5})
+	defer sink.Close()
+
+	if err := sink.Write(context.Background(), []byte(`{"T":"t"}`)); err == nil {
+		t.Fatal("Write() error = nil, want error from a 4xx response")
+	}
+
+	if got := atomic.LoadInt32(&attempts); got != 1 {
+		t.Errorf("attempts = %d, want 1 (4xx is not retryable)", got)
+	}
+}
+
+func TestHTTPSinkCloseDoesNotHangOnUnreachableEndpoint(t *testing.T) {
+	sink := NewHTTPSink(config.HTTPSinkConfig{URL: "http://127.0.0.1:0", BatchSize: 1, MaxRetries: 1000})
+
+	done := make(chan error, 1)
+	go func() {
+		sink.Write(context.Background(), []byte(`{"T":"t"}`))
+		done <- sink.Close()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(defaultCloseFlushTimeout * 2):
+		t.Fatal("Close() did not return within the flush timeout bound")
+	}
+}
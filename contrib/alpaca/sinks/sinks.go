@@ -0,0 +1,79 @@
+// Package sinks defines the output destinations the Alpaca feed can write
+// decoded messages to. A stream can be configured with more than one sink
+// so, for example, the marketstore writer and a raw archive can both
+// consume the same feed.
+package sinks
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/alpacahq/marketstore/v4/contrib/alpaca/config"
+)
+
+// Sink is an output destination for raw messages read off the Alpaca
+// stream. Implementations must be safe to call Write on repeatedly from a
+// single goroutine; Close is called once, when the stream shuts down.
+type Sink interface {
+	Write(ctx context.Context, msg []byte) error
+	Close() error
+}
+
+// ChannelSink forwards every message to a channel, which is the original
+// behavior of the Alpaca plugin: the marketstore writer reads directly off
+// the channel it is constructed with.
+type ChannelSink struct {
+	ch chan<- interface{}
+}
+
+// NewChannelSink wraps ch as a Sink.
+func NewChannelSink(ch chan<- interface{}) *ChannelSink {
+	return &ChannelSink{ch: ch}
+}
+
+func (s *ChannelSink) Write(ctx context.Context, msg []byte) error {
+	select {
+	case s.ch <- msg:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *ChannelSink) Close() error {
+	return nil
+}
+
+// Build constructs the configured sinks in order. channelOut is used for
+// any sink of type config.SinkTypeChannel, preserving the plugin's
+// original single-channel behavior when no other sinks are configured.
+func Build(cfgs []config.SinkConfig, channelOut chan<- interface{}) ([]Sink, error) {
+	built := make([]Sink, 0, len(cfgs))
+	for i := range cfgs {
+		sink, err := build(&cfgs[i], channelOut)
+		if err != nil {
+			return nil, fmt.Errorf("building sink %d (%s): %w", i, cfgs[i].Type, err)
+		}
+		built = append(built, sink)
+	}
+	return built, nil
+}
+
+func build(cfg *config.SinkConfig, channelOut chan<- interface{}) (Sink, error) {
+	switch cfg.Type {
+	case config.SinkTypeChannel:
+		return NewChannelSink(channelOut), nil
+	case config.SinkTypeFile:
+		if cfg.File == nil {
+			return nil, fmt.Errorf("sink type %q requires a File config", cfg.Type)
+		}
+		return NewFileSink(*cfg.File)
+	case config.SinkTypeHTTP:
+		if cfg.HTTP == nil {
+			return nil, fmt.Errorf("sink type %q requires an HTTP config", cfg.Type)
+		}
+		return NewHTTPSink(*cfg.HTTP), nil
+	default:
+		return nil, fmt.Errorf("unknown sink type %q", cfg.Type)
+	}
+}